@@ -0,0 +1,49 @@
+// Package backend abstracts the filesystem operations bobafm needs
+// behind a single interface, so the browser, the paste/rename/delete
+// flow, and the fileop pipeline can all work the same way whether the
+// root they're pointed at is the local disk or a remote host.
+package backend
+
+import (
+	"io"
+	"time"
+)
+
+// Entry is one directory listing entry.
+type Entry struct {
+	Name  string
+	IsDir bool
+}
+
+// Info describes a single path, as returned by Stat.
+type Info struct {
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+	// Dev identifies the physical device/filesystem the path lives on,
+	// so callers can tell a same-backend rename is actually crossing a
+	// mount boundary (which os.Rename can't do) before attempting it.
+	// 0 means unknown — backends that can't report it (SFTP) leave it
+	// zero, and two zero Devs are never treated as the same device.
+	Dev uint64
+}
+
+// Backend is everything bobafm needs from a filesystem. Paths passed to
+// it are always in that backend's own namespace — Join and Dir exist so
+// callers never need to know whether "/" or another separator applies.
+type Backend interface {
+	// String identifies the backend for display, e.g. "local" or
+	// "user@host".
+	String() string
+
+	ReadDir(path string) ([]Entry, error)
+	Stat(path string) (Info, error)
+	Open(path string) (io.ReadCloser, error)
+	Create(path string) (io.WriteCloser, error)
+	Mkdir(path string) error
+	Rename(oldpath, newpath string) error
+	Remove(path string) error
+
+	Join(elem ...string) string
+	Dir(path string) string
+}
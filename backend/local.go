@@ -0,0 +1,51 @@
+package backend
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Local is a Backend backed directly by the local filesystem.
+type Local struct{}
+
+func (Local) String() string { return "local" }
+
+func (Local) ReadDir(path string) ([]Entry, error) {
+	des, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, len(des))
+	for i, d := range des {
+		entries[i] = Entry{Name: d.Name(), IsDir: d.IsDir()}
+	}
+	return entries, nil
+}
+
+func (Local) Stat(path string) (Info, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return Info{}, err
+	}
+	info := Info{IsDir: fi.IsDir(), Size: fi.Size(), ModTime: fi.ModTime()}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		info.Dev = uint64(st.Dev)
+	}
+	return info, nil
+}
+
+func (Local) Open(path string) (io.ReadCloser, error) { return os.Open(path) }
+
+func (Local) Create(path string) (io.WriteCloser, error) { return os.Create(path) }
+
+func (Local) Mkdir(path string) error { return os.MkdirAll(path, 0755) }
+
+func (Local) Rename(oldpath, newpath string) error { return os.Rename(oldpath, newpath) }
+
+func (Local) Remove(path string) error { return os.RemoveAll(path) }
+
+func (Local) Join(elem ...string) string { return filepath.Join(elem...) }
+
+func (Local) Dir(path string) string { return filepath.Dir(path) }
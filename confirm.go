@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/StikyPiston/bobafm/fileop"
+)
+
+type opKind int
+
+const (
+	opCopy opKind = iota
+	opMove
+	opMoveCrossDevice
+	opMoveCrossBackend
+	opDelete
+)
+
+// plannedOp is one concrete (src,dst,op) tuple a batch is about to run,
+// with the checks a user would want to see before committing: does dst
+// already exist (on disk, or because an earlier op in the same batch
+// already claims it), and does the move need to fall back to
+// copy+delete because src and dst can't be renamed directly — either
+// they're on different backends entirely, or they're the same backend
+// but different physical devices/mounts, which os.Rename can't cross.
+// A collision is not blocked — Copy/Move trash the existing dst instead
+// of overwriting it in place, so it stays recoverable through the usual
+// undo.
+type plannedOp struct {
+	src, dst  fileop.Location
+	kind      opKind
+	collision bool
+	size      int64
+}
+
+func (o plannedOp) label() string {
+	var line string
+	switch o.kind {
+	case opCopy:
+		line = fmt.Sprintf("copy    %s -> %s", o.src.Path, o.dst.Path)
+	case opMove:
+		line = fmt.Sprintf("move    %s -> %s", o.src.Path, o.dst.Path)
+	case opMoveCrossDevice:
+		line = fmt.Sprintf("move    %s -> %s (cross-device: copy+delete)", o.src.Path, o.dst.Path)
+	case opMoveCrossBackend:
+		line = fmt.Sprintf("move    %s -> %s (cross-backend: copy+delete)", o.src.Path, o.dst.Path)
+	case opDelete:
+		line = fmt.Sprintf("delete  %s", o.src.Path)
+	}
+	if o.collision {
+		line += " [overwrites existing, recoverable via undo]"
+	}
+	return line
+}
+
+// planPaste builds the operation list a Paste would run against the
+// current clipboard, without touching the filesystem.
+func planPaste(m *model) []plannedOp {
+	ops := make([]plannedOp, 0, len(m.clipboard))
+	dstCount := map[string]int{}
+	for _, src := range m.clipboard {
+		dst := fileop.Location{Backend: m.root, Path: m.root.Join(m.cwd, baseName(src.Path))}
+		op := plannedOp{src: src, dst: dst}
+		switch {
+		case m.clipMode == ClipCopy:
+			op.kind = opCopy
+		case src.Backend != m.root:
+			op.kind = opMoveCrossBackend
+		default:
+			op.kind = opMove
+			srcInfo, srcErr := src.Backend.Stat(src.Path)
+			dirInfo, dirErr := m.root.Stat(m.cwd)
+			if srcErr == nil && dirErr == nil && (srcInfo.Dev == 0 || dirInfo.Dev == 0 || srcInfo.Dev != dirInfo.Dev) {
+				op.kind = opMoveCrossDevice
+			}
+		}
+		if _, err := dst.Backend.Stat(dst.Path); err == nil {
+			op.collision = true
+		}
+		if info, err := src.Backend.Stat(src.Path); err == nil {
+			op.size = info.Size
+		}
+		dstCount[locKey(dst.Backend, dst.Path)]++
+		ops = append(ops, op)
+	}
+	// A destination that two ops in this same batch both target isn't
+	// caught by the Stat check above, since neither one exists yet when
+	// planning runs — but the second to execute will still clobber the
+	// first, so flag both.
+	for i := range ops {
+		if dstCount[locKey(ops[i].dst.Backend, ops[i].dst.Path)] > 1 {
+			ops[i].collision = true
+		}
+	}
+	return ops
+}
+
+// planDeletes builds the operation list a batch Delete would run
+// against the given locations.
+func planDeletes(locs []fileop.Location) []plannedOp {
+	ops := make([]plannedOp, 0, len(locs))
+	for _, loc := range locs {
+		op := plannedOp{src: loc, kind: opDelete}
+		if info, err := loc.Backend.Stat(loc.Path); err == nil {
+			op.size = info.Size
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// confirmState is a dry-run preview of a batch of operations: it renders
+// every planned (src,dst,op) tuple plus a byte estimate, and only runs
+// them — through the fileop pipeline, so a partial failure rolls back —
+// once the user confirms with y.
+type confirmState struct{ ops []plannedOp }
+
+func newConfirmState(m *model, ops []plannedOp) state {
+	var b strings.Builder
+	var total int64
+	for _, op := range ops {
+		b.WriteString(op.label())
+		b.WriteString("\n")
+		total += op.size
+	}
+	fmt.Fprintf(&b, "\n%d operation(s), ~%d bytes total — y to execute, n/esc to abort\n", len(ops), total)
+	m.viewport.SetContent(b.String())
+	return confirmState{ops: ops}
+}
+
+func (s confirmState) view(m *model) string { return m.viewport.View() }
+
+func (s confirmState) handle(m *model, msg tea.Msg) (state, tea.Cmd) {
+	if km, ok := msg.(tea.KeyMsg); ok {
+		switch km.String() {
+		case "y":
+			actions := make([]fileop.Action, 0, len(s.ops))
+			for _, op := range s.ops {
+				switch op.kind {
+				case opCopy:
+					actions = append(actions, fileop.Copy(op.src, op.dst))
+				case opMove:
+					actions = append(actions, fileop.Move(op.src, op.dst))
+				case opMoveCrossDevice, opMoveCrossBackend:
+					actions = append(actions, fileop.Copy(op.src, op.dst), fileop.Remove(op.src))
+				case opDelete:
+					actions = append(actions, fileop.Remove(op.src))
+				}
+			}
+			if reverses, err := fileop.Run(actions); err == nil {
+				m.journal.push(reverses)
+			}
+			m.clipboard = nil
+			m.clipMode = ClipNone
+			m.marked = map[string]fileop.Location{}
+			m.refreshBrowse()
+			return idleState{}, nil
+		case "n", "esc":
+			return idleState{}, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(msg)
+	return s, cmd
+}
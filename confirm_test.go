@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/StikyPiston/bobafm/backend"
+	"github.com/StikyPiston/bobafm/fileop"
+)
+
+func TestPlanPasteFlagsIntraBatchCollision(t *testing.T) {
+	root := t.TempDir()
+	be := backend.Local{}
+
+	srcA := filepath.Join(root, "a")
+	srcB := filepath.Join(root, "b")
+	dst := filepath.Join(root, "dst")
+	for _, dir := range []string{srcA, srcB, dst} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fileA := filepath.Join(srcA, "note.txt")
+	fileB := filepath.Join(srcB, "note.txt")
+	if err := os.WriteFile(fileA, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fileB, []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &model{
+		root: be,
+		cwd:  dst,
+		clipboard: []fileop.Location{
+			{Backend: be, Path: fileA},
+			{Backend: be, Path: fileB},
+		},
+		clipMode: ClipCopy,
+	}
+
+	ops := planPaste(m)
+	if len(ops) != 2 {
+		t.Fatalf("len(ops) = %d, want 2", len(ops))
+	}
+	for _, op := range ops {
+		if !op.collision {
+			t.Errorf("op for %s: collision = false, want true (both ops paste to %s/note.txt)", op.src.Path, dst)
+		}
+	}
+}
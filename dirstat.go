@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/StikyPiston/bobafm/backend"
+)
+
+// dirStats is a recursive scan of a directory: how many entries it
+// contains, their total byte size, and an aggregate hash that changes
+// if any file's content or relative path changes. changed is set by a
+// later rescan that finds the hash no longer matches.
+type dirStats struct {
+	mtime   time.Time
+	count   int
+	size    int64
+	hash    string
+	changed bool
+}
+
+// scanDir walks dir recursively and computes its stats. Entries are
+// visited depth-first; the aggregate hash folds in each file's content
+// and its path relative to dir, in sorted order, so the same tree
+// always produces the same hash regardless of listing order.
+func scanDir(be backend.Backend, dir string) (*dirStats, error) {
+	root, err := be.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int
+	var size int64
+	var files []string
+	if err := walkDirStats(be, dir, dir, &count, &size, &files); err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, rel := range files {
+		r, err := be.Open(be.Join(dir, rel))
+		if err != nil {
+			return nil, err
+		}
+		h.Write(readAll(r))
+		r.Close()
+		h.Write([]byte(rel))
+	}
+
+	return &dirStats{mtime: root.ModTime, count: count, size: size, hash: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+func walkDirStats(be backend.Backend, root, dir string, count *int, size *int64, files *[]string) error {
+	entries, err := be.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		full := be.Join(dir, e.Name)
+		*count++
+		if e.IsDir {
+			if err := walkDirStats(be, root, full, count, size, files); err != nil {
+				return err
+			}
+			continue
+		}
+		info, err := be.Stat(full)
+		if err != nil {
+			return err
+		}
+		*size += info.Size
+		*files = append(*files, relPath(root, full))
+	}
+	return nil
+}
+
+// relPath strips dir's prefix off full, leaving a relative path. It
+// avoids path/filepath since the separator depends on the backend.
+func relPath(dir, full string) string {
+	rel := strings.TrimPrefix(full, dir)
+	return strings.TrimLeft(rel, "/\\")
+}
+
+// cachedScanDir returns dir's stats from cache, keyed by its absolute
+// path, as long as dir's own mtime still matches what was recorded at
+// the last scan. A changed mtime means something was added or removed
+// directly inside dir, so it rescans and refreshes the cache entry.
+func cachedScanDir(cache map[string]*dirStats, be backend.Backend, dir string) (*dirStats, error) {
+	key := locKey(be, dir)
+	info, err := be.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if cached, ok := cache[key]; ok && cached.mtime.Equal(info.ModTime) {
+		return cached, nil
+	}
+	st, err := scanDir(be, dir)
+	if err != nil {
+		return nil, err
+	}
+	cache[key] = st
+	return st, nil
+}
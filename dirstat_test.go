@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/StikyPiston/bobafm/backend"
+)
+
+func TestScanDirHashIsDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	be := backend.Local{}
+
+	if err := os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := scanDir(be, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := scanDir(be, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.hash != second.hash {
+		t.Fatalf("repeated scans of the same tree produced different hashes: %q != %q", first.hash, second.hash)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	third, err := scanDir(be, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.hash == first.hash {
+		t.Fatal("changing a file's content should change the aggregate hash")
+	}
+}
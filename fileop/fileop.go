@@ -0,0 +1,202 @@
+// Package fileop models filesystem mutations as chainable, composable
+// actions against a backend.Backend. Every Action knows how to undo
+// itself: Do performs the mutation and returns the Reverse action that
+// restores the prior state, so a sequence of actions can be executed,
+// journaled, and rolled back as a unit — regardless of which backend(s)
+// the paths involved live on.
+package fileop
+
+import (
+	"io"
+
+	"github.com/StikyPiston/bobafm/backend"
+)
+
+// Location pins a path to the backend it lives on, so actions that
+// cross backends (e.g. copying from a remote mount to the local disk)
+// carry enough information to do so.
+type Location struct {
+	Backend backend.Backend
+	Path    string
+}
+
+// Action is a single filesystem mutation. Do performs the mutation and
+// returns the Reverse action that undoes it.
+type Action interface {
+	Do() (Reverse, error)
+}
+
+// Reverse is the action that undoes an Action. It is itself an Action,
+// so undoing a Reverse yields the original (or an equivalent) forward
+// action — this is what lets undo/redo share the same stack machinery.
+type Reverse = Action
+
+type noopAction struct{}
+
+func (noopAction) Do() (Reverse, error) { return noopAction{}, nil }
+
+type mkdirAction struct{ loc Location }
+
+// Mkdir creates loc (and any missing parents). If it already exists the
+// action is a no-op and its reverse is a no-op too, so undo never
+// removes a directory it didn't create.
+func Mkdir(loc Location) Action { return mkdirAction{loc} }
+
+func (a mkdirAction) Do() (Reverse, error) {
+	if _, err := a.loc.Backend.Stat(a.loc.Path); err == nil {
+		return noopAction{}, nil
+	}
+	if err := a.loc.Backend.Mkdir(a.loc.Path); err != nil {
+		return nil, err
+	}
+	return removeAction{a.loc}, nil
+}
+
+type mkfileAction struct {
+	loc  Location
+	data []byte
+}
+
+// Mkfile creates a new file at loc with the given contents. It fails if
+// the file already exists, matching the old create-new-file behaviour.
+func Mkfile(loc Location, data []byte) Action { return mkfileAction{loc, data} }
+
+func (a mkfileAction) Do() (Reverse, error) {
+	if _, err := a.loc.Backend.Stat(a.loc.Path); err == nil {
+		return nil, errExists(a.loc.Path)
+	}
+	w, err := a.loc.Backend.Create(a.loc.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+	if len(a.data) > 0 {
+		if _, err := w.Write(a.data); err != nil {
+			return nil, err
+		}
+	}
+	return removeAction{a.loc}, nil
+}
+
+// seqAction runs a fixed list of actions in order as a single logical
+// step, via Run, then re-packages the resulting reverses as a single
+// Action of their own (in the right order to undo the whole step).
+// This is what lets Copy/Move compose an overwrite-protection step
+// with the underlying copy/rename and still hand back one Reverse.
+type seqAction []Action
+
+func (s seqAction) Do() (Reverse, error) {
+	reverses, err := Run(s)
+	if err != nil {
+		return nil, err
+	}
+	rev := make(seqAction, len(reverses))
+	for i, r := range reverses {
+		rev[len(reverses)-1-i] = r
+	}
+	return rev, nil
+}
+
+type copyDataAction struct{ src, dst Location }
+
+// Do copies src to dst, streaming through Open/Create so src and dst
+// may live on different backends. It requires dst not to already
+// exist; the overwrite case is handled by copyAction.
+func (a copyDataAction) Do() (Reverse, error) {
+	in, err := a.src.Backend.Open(a.src.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	out, err := a.dst.Backend.Create(a.dst.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		return nil, err
+	}
+	return removeAction{a.dst}, nil
+}
+
+type copyAction struct{ src, dst Location }
+
+// Copy copies src to dst. If dst already exists, it is trashed first
+// (same as Remove) rather than overwritten in place, so the reverse can
+// restore the original dst as well as remove the new copy — undo fully
+// reverts the collision, not just the half of it that was new.
+func Copy(src, dst Location) Action { return copyAction{src, dst} }
+
+func (a copyAction) Do() (Reverse, error) {
+	var actions []Action
+	if _, err := a.dst.Backend.Stat(a.dst.Path); err == nil {
+		actions = append(actions, removeAction{a.dst})
+	}
+	actions = append(actions, copyDataAction{a.src, a.dst})
+	return seqAction(actions).Do()
+}
+
+type moveDataAction struct{ src, dst Location }
+
+// Do renames src to dst. Both must live on the same backend — a
+// cross-backend move is a Copy followed by a Remove. It requires dst
+// not to already exist; the overwrite case is handled by moveAction.
+func (a moveDataAction) Do() (Reverse, error) {
+	if err := a.src.Backend.Rename(a.src.Path, a.dst.Path); err != nil {
+		return nil, err
+	}
+	return moveDataAction{a.dst, a.src}, nil
+}
+
+type moveAction struct{ src, dst Location }
+
+// Move renames src to dst. If dst already exists, it is trashed first
+// (same as Remove) rather than overwritten in place, so the reverse can
+// restore the original dst as well as move the new file back to src.
+func Move(src, dst Location) Action { return moveAction{src, dst} }
+
+func (a moveAction) Do() (Reverse, error) {
+	var actions []Action
+	if _, err := a.dst.Backend.Stat(a.dst.Path); err == nil {
+		actions = append(actions, removeAction{a.dst})
+	}
+	actions = append(actions, moveDataAction{a.src, a.dst})
+	return seqAction(actions).Do()
+}
+
+type removeAction struct{ loc Location }
+
+// Remove deletes loc. It is reversible: the target is moved into a
+// trash directory on the same backend rather than destroyed outright,
+// and the reverse moves it back.
+func Remove(loc Location) Action { return removeAction{loc} }
+
+func (a removeAction) Do() (Reverse, error) {
+	trash, err := trashPath(a.loc)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.loc.Backend.Rename(a.loc.Path, trash.Path); err != nil {
+		return nil, err
+	}
+	return moveAction{trash, a.loc}, nil
+}
+
+// Run executes actions in order, collecting their reverses. If any
+// action fails, the actions that already succeeded are rolled back (in
+// reverse order) before the error is returned, so a partially-applied
+// batch never leaves the tree half-mutated.
+func Run(actions []Action) ([]Reverse, error) {
+	reverses := make([]Reverse, 0, len(actions))
+	for _, a := range actions {
+		rev, err := a.Do()
+		if err != nil {
+			for i := len(reverses) - 1; i >= 0; i-- {
+				reverses[i].Do()
+			}
+			return nil, err
+		}
+		reverses = append(reverses, rev)
+	}
+	return reverses, nil
+}
@@ -0,0 +1,66 @@
+package fileop
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/StikyPiston/bobafm/backend"
+)
+
+func TestRunRollsBackOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	be := backend.Local{}
+
+	newDir := filepath.Join(dir, "sub")
+	existing := filepath.Join(dir, "exists.txt")
+	if err := os.WriteFile(existing, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	actions := []Action{
+		Mkdir(Location{Backend: be, Path: newDir}),
+		Mkfile(Location{Backend: be, Path: existing}, nil), // fails: already exists
+	}
+
+	if _, err := Run(actions); err == nil {
+		t.Fatal("expected Run to fail when a later action errors")
+	}
+
+	if _, err := os.Stat(newDir); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be rolled back, stat err = %v", newDir, err)
+	}
+	got, err := os.ReadFile(existing)
+	if err != nil || string(got) != "keep" {
+		t.Fatalf("existing file should be untouched, got %q, err %v", got, err)
+	}
+}
+
+func TestCopyOverwriteIsReversible(t *testing.T) {
+	dir := t.TempDir()
+	be := backend.Local{}
+
+	src := filepath.Join(dir, "src.txt")
+	dst := filepath.Join(dir, "dst.txt")
+	if err := os.WriteFile(src, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rev, err := Copy(Location{Backend: be, Path: src}, Location{Backend: be, Path: dst}).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(dst); string(got) != "new" {
+		t.Fatalf("dst = %q, want %q", got, "new")
+	}
+
+	if _, err := rev.Do(); err != nil {
+		t.Fatalf("reverse failed: %v", err)
+	}
+	if got, _ := os.ReadFile(dst); string(got) != "old" {
+		t.Fatalf("after undo, dst = %q, want %q (overwritten file should be restored)", got, "old")
+	}
+}
@@ -0,0 +1,91 @@
+package fileop
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/StikyPiston/bobafm/backend"
+)
+
+const trashDirName = ".bobafm-trash"
+
+type existsError string
+
+func (e existsError) Error() string { return string(e) + " already exists" }
+
+func errExists(path string) error { return existsError(path) }
+
+var trashSeq uint64
+
+// trashPath returns a fresh Location under a ".bobafm-trash" directory
+// sitting next to loc, on the same backend, creating the directory on
+// first use. Paths are kept unique via a monotonic counter so repeated
+// removes of same-named files never collide.
+func trashPath(loc Location) (Location, error) {
+	dir := loc.Backend.Join(loc.Backend.Dir(loc.Path), trashDirName)
+	if err := loc.Backend.Mkdir(dir); err != nil {
+		return Location{}, err
+	}
+	n := atomic.AddUint64(&trashSeq, 1)
+	name := fmt.Sprintf("%d-%s", n, baseName(loc.Path))
+	return Location{Backend: loc.Backend, Path: loc.Backend.Join(dir, name)}, nil
+}
+
+// isTrashed reports whether loc sits inside a .bobafm-trash directory,
+// i.e. whether it is something Remove moved aside rather than an
+// original file.
+func isTrashed(loc Location) bool {
+	return strings.Contains(loc.Path, trashDirName)
+}
+
+// Purge permanently deletes whatever trashed files a discarded batch of
+// reverses still points at. It is the counterpart to Remove's
+// trash-instead-of-delete behaviour: once a reverse is no longer
+// reachable (the undo journal evicted it, or the user emptied trash),
+// the file it points at would otherwise sit in .bobafm-trash forever.
+// Reverses that don't point into trash are left alone.
+func Purge(reverses []Reverse) {
+	for _, r := range reverses {
+		purgeOne(r)
+	}
+}
+
+func purgeOne(r Reverse) {
+	switch a := r.(type) {
+	case moveAction:
+		if isTrashed(a.src) {
+			a.src.Backend.Remove(a.src.Path)
+		}
+	case moveDataAction:
+		if isTrashed(a.src) {
+			a.src.Backend.Remove(a.src.Path)
+		}
+	case seqAction:
+		Purge(a)
+	}
+}
+
+// EmptyTrash permanently deletes the .bobafm-trash directory sitting
+// next to dir, if any. It is the one reachable "empty trash" action in
+// bobafm; anything undoable that pointed into it stops being undoable
+// once this runs.
+func EmptyTrash(be backend.Backend, dir string) error {
+	trash := be.Join(dir, trashDirName)
+	if _, err := be.Stat(trash); err != nil {
+		return nil
+	}
+	return be.Remove(trash)
+}
+
+// baseName splits off the final path element without pulling in a
+// backend-specific path package, since a Location's path separator
+// depends on which backend it belongs to.
+func baseName(path string) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '/' || path[i] == '\\' {
+			return path[i+1:]
+		}
+	}
+	return path
+}
@@ -0,0 +1,102 @@
+package main
+
+import "github.com/StikyPiston/bobafm/fileop"
+
+// journal keeps a bounded history of reverses so destructive operations
+// (create, rename, paste, delete) can be undone and redone. Each entry
+// is a step: the set of reverses produced by one user action, applied
+// and rolled back together.
+type journal struct {
+	undo [][]fileop.Reverse
+	redo [][]fileop.Reverse
+	cap  int
+}
+
+func newJournal(cap int) *journal {
+	return &journal{cap: cap}
+}
+
+// push records a new step and clears the redo stack, matching the usual
+// editor convention that a fresh action invalidates old redos. A step
+// evicted by the cap is gone for good, so anything it still trashed is
+// purged rather than left on disk forever.
+func (j *journal) push(step []fileop.Reverse) {
+	if len(step) == 0 {
+		return
+	}
+	j.undo = append(j.undo, step)
+	if over := len(j.undo) - j.cap; over > 0 {
+		for _, s := range j.undo[:over] {
+			fileop.Purge(s)
+		}
+		j.undo = j.undo[over:]
+	}
+	j.redo = nil
+}
+
+func (j *journal) canUndo() bool { return len(j.undo) > 0 }
+func (j *journal) canRedo() bool { return len(j.redo) > 0 }
+
+// undoStep applies the most recent step's reverses and pushes the actions
+// that undo *that* (i.e. the original forward actions) onto the redo
+// stack. If any reverse in the step fails — e.g. it points into
+// .bobafm-trash and the trash was emptied out from under it — whatever
+// of the step already ran is rolled back and the step is left in place
+// on the undo stack rather than silently discarded, so the caller can
+// tell nothing happened and the user can retry.
+func (j *journal) undoStep() bool {
+	if !j.canUndo() {
+		return false
+	}
+	step := j.undo[len(j.undo)-1]
+
+	redone := make([]fileop.Reverse, 0, len(step))
+	for i := len(step) - 1; i >= 0; i-- {
+		rev, err := step[i].Do()
+		if err != nil {
+			for k := len(redone) - 1; k >= 0; k-- {
+				redone[k].Do()
+			}
+			return false
+		}
+		redone = append(redone, rev)
+	}
+
+	j.undo = j.undo[:len(j.undo)-1]
+	if len(j.redo) >= j.cap {
+		fileop.Purge(j.redo[0])
+		j.redo = j.redo[1:]
+	}
+	j.redo = append(j.redo, redone)
+	return true
+}
+
+// redoStep is undoStep's mirror image: it re-applies the most recently
+// undone step and pushes its reverse back onto the undo stack, with the
+// same all-or-nothing behaviour on failure.
+func (j *journal) redoStep() bool {
+	if !j.canRedo() {
+		return false
+	}
+	step := j.redo[len(j.redo)-1]
+
+	undone := make([]fileop.Reverse, 0, len(step))
+	for i := len(step) - 1; i >= 0; i-- {
+		rev, err := step[i].Do()
+		if err != nil {
+			for k := len(undone) - 1; k >= 0; k-- {
+				undone[k].Do()
+			}
+			return false
+		}
+		undone = append(undone, rev)
+	}
+
+	j.redo = j.redo[:len(j.redo)-1]
+	if len(j.undo) >= j.cap {
+		fileop.Purge(j.undo[0])
+		j.undo = j.undo[1:]
+	}
+	j.undo = append(j.undo, undone)
+	return true
+}
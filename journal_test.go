@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/StikyPiston/bobafm/fileop"
+)
+
+// recordAction is a fake fileop.Action for exercising journal undo/redo
+// without touching a filesystem: Do appends its name to log and returns
+// an action that undoes it.
+type recordAction struct {
+	log  *[]string
+	name string
+}
+
+func (a recordAction) Do() (fileop.Reverse, error) {
+	*a.log = append(*a.log, a.name)
+	return recordAction{log: a.log, name: "un-" + a.name}, nil
+}
+
+// failingAction always errors, to exercise a reverse that can't run
+// (e.g. its trash entry was emptied out from under it).
+type failingAction struct{}
+
+func (failingAction) Do() (fileop.Reverse, error) { return nil, errors.New("boom") }
+
+func TestJournalUndoRedo(t *testing.T) {
+	var log []string
+	j := newJournal(10)
+
+	j.push([]fileop.Reverse{recordAction{log: &log, name: "a"}})
+	j.push([]fileop.Reverse{recordAction{log: &log, name: "b"}})
+
+	if !j.undoStep() {
+		t.Fatal("expected undo to succeed")
+	}
+	if len(log) != 1 || log[0] != "b" {
+		t.Fatalf("log = %v, want [b] (undoing the most recent push runs its reverse)", log)
+	}
+	if !j.canRedo() {
+		t.Fatal("expected redo to be available after undo")
+	}
+	if !j.redoStep() {
+		t.Fatal("expected redo to succeed")
+	}
+	if len(log) != 2 || log[1] != "un-b" {
+		t.Fatalf("log = %v, want [b un-b]", log)
+	}
+}
+
+func TestJournalEvictsOldestOnOverflow(t *testing.T) {
+	var log []string
+	j := newJournal(1)
+
+	j.push([]fileop.Reverse{recordAction{log: &log, name: "a"}})
+	j.push([]fileop.Reverse{recordAction{log: &log, name: "b"}})
+
+	if len(j.undo) != 1 {
+		t.Fatalf("len(j.undo) = %d, want 1", len(j.undo))
+	}
+	if !j.undoStep() {
+		t.Fatal("expected undo to succeed")
+	}
+	if len(log) != 1 || log[0] != "b" {
+		t.Fatalf("log = %v, want [b] (the evicted step \"a\" must not still be undoable)", log)
+	}
+	if j.canUndo() {
+		t.Fatal("expected undo stack to be empty after the single retained step was undone")
+	}
+}
+
+func TestJournalUndoKeepsStepWhenReverseFails(t *testing.T) {
+	var log []string
+	j := newJournal(10)
+
+	j.push([]fileop.Reverse{recordAction{log: &log, name: "a"}, failingAction{}})
+
+	if j.undoStep() {
+		t.Fatal("expected undo to fail when one of the step's reverses errors")
+	}
+	if !j.canUndo() {
+		t.Fatal("a step that failed to undo must stay on the undo stack for retry")
+	}
+	if j.canRedo() {
+		t.Fatal("a failed undo must not push anything onto the redo stack")
+	}
+	if len(log) != 0 {
+		t.Fatalf("log = %v, want [] (reverses run last-first, so the failing one runs before \"a\" and nothing else should have run)", log)
+	}
+}
@@ -2,147 +2,219 @@ package main
 
 import (
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
-)
 
-type Mode int
-const (
-	ModeBrowse Mode = iota
-	ModeMounts
-	ModeInput
-	ModeView
+	"github.com/StikyPiston/bobafm/backend"
+	"github.com/StikyPiston/bobafm/fileop"
 )
+
+// journalCap bounds how many undo/redo steps are kept in memory.
+const journalCap = 50
+
 type ClipboardMode int
+
 const (
 	ClipNone ClipboardMode = iota
 	ClipCopy
 	ClipCut
 )
 
-type model struct {
-	mode Mode
-	cwd  string
-
-	showHidden bool
-	browse     list.Model
-	mounts     list.Model
-	input      textinput.Model
-	view       viewport.Model
+// remoteRoot is a connected SFTP mount, offered from the mounts panel's
+// Remote section alongside local block devices.
+type remoteRoot struct {
+	spec string // display form, e.g. "user@host:/path"
+	be   *backend.SFTP
+	path string
+}
 
-	marked    map[string]bool
-	clipboard []string
+type model struct {
+	state state
+	root  backend.Backend
+	cwd   string
+
+	showHidden  bool
+	browse      list.Model
+	mounts      list.Model
+	input       textinput.Model
+	textarea    textarea.Model
+	viewport    viewport.Model
+	remoteRoots []*remoteRoot
+
+	overlays map[string]*overlay
+	dirStats map[string]*dirStats
+
+	marked    map[string]fileop.Location
+	clipboard []fileop.Location
 	clipMode  ClipboardMode
 
-	editor      string
-	inputTarget string
-	keys        *keyMap
+	editor string
+	keys   *keyMap
+
+	journal *journal
 }
 
 type keyMap struct {
 	Up, Down, Enter, Mark, Yank, Cut, Paste,
-	New, Rename, Delete, View, Mount, ToggleHidden,
-	Filter, Quit, Back key.Binding
+	NewFile, NewDir, Rename, Move, Delete, View, Save, Mount, AddMount, ToggleHidden,
+	Filter, Quit, Back, Undo, Redo, Scan, Verify, EmptyTrash key.Binding
 }
 
 func newKeyMap() *keyMap {
 	return &keyMap{
-		Up: key.NewBinding(key.WithKeys("up","k"), key.WithHelp("↑/k","up")),
-		Down: key.NewBinding(key.WithKeys("down","j"), key.WithHelp("↓/j","down")),
-		Enter: key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter","open/cd")),
-		Mark: key.NewBinding(key.WithKeys(" "), key.WithHelp("space","mark/unmark")),
-		Yank: key.NewBinding(key.WithKeys("y"), key.WithHelp("y","yank")),
-		Cut: key.NewBinding(key.WithKeys("x"), key.WithHelp("x","cut")),
-		Paste: key.NewBinding(key.WithKeys("p"), key.WithHelp("p","paste")),
-		New: key.NewBinding(key.WithKeys("i"), key.WithHelp("i","new file/dir")),
-		Rename: key.NewBinding(key.WithKeys("r"), key.WithHelp("r","rename")),
-		Delete: key.NewBinding(key.WithKeys("d"), key.WithHelp("d","delete")),
-		View: key.NewBinding(key.WithKeys("v"), key.WithHelp("v","view file")),
-		Mount: key.NewBinding(key.WithKeys("m"), key.WithHelp("m","mount menu")),
-		ToggleHidden: key.NewBinding(key.WithKeys("h"), key.WithHelp("h","toggle hidden")),
-		Filter: key.NewBinding(key.WithKeys("/"), key.WithHelp("/","filter")),
-		Quit: key.NewBinding(key.WithKeys("q"), key.WithHelp("q","quit")),
-		Back: key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc","back")),
+		Up:           key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "up")),
+		Down:         key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓/j", "down")),
+		Enter:        key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "open/cd")),
+		Mark:         key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "mark/unmark")),
+		Yank:         key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "yank")),
+		Cut:          key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "cut")),
+		Paste:        key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "paste")),
+		NewFile:      key.NewBinding(key.WithKeys("i"), key.WithHelp("i", "new file")),
+		NewDir:       key.NewBinding(key.WithKeys("I"), key.WithHelp("I", "new directory")),
+		Rename:       key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "rename")),
+		Move:         key.NewBinding(key.WithKeys("M"), key.WithHelp("M", "move to path")),
+		Delete:       key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "delete")),
+		View:         key.NewBinding(key.WithKeys("v"), key.WithHelp("v", "view/edit file")),
+		Save:         key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("ctrl+s", "save")),
+		Mount:        key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "mount menu")),
+		AddMount:     key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "add remote mount")),
+		ToggleHidden: key.NewBinding(key.WithKeys("h"), key.WithHelp("h", "toggle hidden")),
+		Filter:       key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "filter")),
+		Quit:         key.NewBinding(key.WithKeys("q"), key.WithHelp("q", "quit")),
+		Back:         key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "back")),
+		Undo:         key.NewBinding(key.WithKeys("u"), key.WithHelp("u", "undo")),
+		Redo:         key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl+r", "redo")),
+		Scan:         key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "scan directory stats")),
+		Verify:       key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "check for changes since scan")),
+		EmptyTrash:   key.NewBinding(key.WithKeys("T"), key.WithHelp("T", "empty trash in this directory")),
 	}
 }
 
 func (k *keyMap) all() []key.Binding {
-	return []key.Binding{k.Up,k.Down,k.Enter,k.Mark,k.Yank,k.Cut,k.Paste,k.New,k.Rename,k.Delete,k.View,k.Mount,k.ToggleHidden,k.Filter,k.Back,k.Quit}
+	return []key.Binding{k.Up, k.Down, k.Enter, k.Mark, k.Yank, k.Cut, k.Paste, k.NewFile, k.NewDir, k.Rename, k.Move, k.Delete, k.View, k.Save, k.Mount, k.AddMount, k.ToggleHidden, k.Filter, k.Back, k.Undo, k.Redo, k.Scan, k.Verify, k.EmptyTrash, k.Quit}
 }
 
 type fileItem struct {
-	name string
-	path string
-	isDir bool
+	name     string
+	path     string
+	backend  backend.Backend
+	isDir    bool
 	isParent bool
 }
-func (i fileItem) Title() string { return i.name }
+
+func (i fileItem) Title() string       { return i.name }
 func (i fileItem) FilterValue() string { return i.name }
+func (i fileItem) loc() fileop.Location {
+	return fileop.Location{Backend: i.backend, Path: i.path}
+}
 func (i fileItem) Description() string {
 	desc := "File"
-	if i.isParent { desc="Parent Directory" } else if i.isDir { desc="Directory" }
-	if !i.isParent && app.marked[i.path] { desc += " [Marked]" }
+	if i.isParent {
+		desc = "Parent Directory"
+	} else if i.isDir {
+		desc = "Directory"
+	}
+	if !i.isParent {
+		if ov, ok := app.overlays[locKey(i.backend, i.path)]; ok && ov.dirty() {
+			desc += " [Modified]"
+		}
+		if i.isDir {
+			if st, ok := app.dirStats[locKey(i.backend, i.path)]; ok {
+				desc += fmt.Sprintf(" — %d entries, %d bytes", st.count, st.size)
+				if st.changed {
+					desc += " [Changed]"
+				}
+			}
+		}
+		if _, ok := app.marked[locKey(i.backend, i.path)]; ok {
+			desc += " [Marked]"
+		}
+	}
 	return desc
 }
 
-type mountItem struct { dev, mount string }
-func (i mountItem) Title() string { return i.dev }
+// locKey identifies a Location across backends, since the same path
+// string can mean different files on different hosts.
+func locKey(be backend.Backend, path string) string { return be.String() + ":" + path }
+
+type mountItem struct {
+	dev, mount string
+	remote     bool
+	root       *remoteRoot
+}
+
+func (i mountItem) Title() string       { return i.dev }
 func (i mountItem) FilterValue() string { return i.dev }
 func (i mountItem) Description() string {
-	if i.mount=="" { return "Unmounted" }
-	return "Mounted at "+i.mount
+	if i.remote {
+		return "Remote, mounted at " + i.root.path
+	}
+	if i.mount == "" {
+		return "Unmounted"
+	}
+	return "Mounted at " + i.mount
 }
 
 var app model
 
 func initialModel() model {
-	cwd,_ := os.Getwd()
+	cwd, _ := os.Getwd()
 	keys := newKeyMap()
 	delegate := list.NewDefaultDelegate()
 	delegate.ShowDescription = true
 
-	b := list.New(nil, delegate, 0,0)
+	b := list.New(nil, delegate, 0, 0)
 	b.SetFilteringEnabled(true)
 	b.Title = "bobafm"
 	b.AdditionalFullHelpKeys = func() []key.Binding { return keys.all() }
 
-	m := list.New(nil, list.NewDefaultDelegate(),0,0)
+	m := list.New(nil, list.NewDefaultDelegate(), 0, 0)
 
 	ti := textinput.New()
 	ti.Placeholder = "new-file.txt or folder/"
 	ti.Focus()
 
-	vp := viewport.New(0,0)
+	ta := textarea.New()
+	ta.ShowLineNumbers = false
+
+	vp := viewport.New(0, 0)
+
 	editor := os.Getenv("EDITOR")
-	if editor=="" { editor="vi" }
+	if editor == "" {
+		editor = "vi"
+	}
 
 	app = model{
-		mode: ModeBrowse,
-		cwd: cwd,
+		state:      idleState{},
+		root:       backend.Local{},
+		cwd:        cwd,
 		showHidden: false,
-		browse: b,
-		mounts: m,
-		input: ti,
-		view: vp,
-		marked: make(map[string]bool),
-		editor: editor,
-		keys: keys,
+		browse:     b,
+		mounts:     m,
+		input:      ti,
+		textarea:   ta,
+		viewport:   vp,
+		overlays:   make(map[string]*overlay),
+		dirStats:   make(map[string]*dirStats),
+		marked:     make(map[string]fileop.Location),
+		editor:     editor,
+		keys:       keys,
+		journal:    newJournal(journalCap),
 	}
 	app.refreshBrowse()
 	return app
 }
 
 func (m *model) refreshBrowse() {
-	entries, err := os.ReadDir(m.cwd)
+	entries, err := m.root.ReadDir(m.cwd)
 	if err != nil {
 		return
 	}
@@ -150,17 +222,17 @@ func (m *model) refreshBrowse() {
 	var dirs []list.Item
 	var files []list.Item
 
-	parent := filepath.Dir(m.cwd)
+	parent := m.root.Dir(m.cwd)
 	if parent != m.cwd {
-		dirs = append(dirs, fileItem{name: "..", path: parent, isDir: true, isParent: true})
+		dirs = append(dirs, fileItem{name: "..", path: parent, backend: m.root, isDir: true, isParent: true})
 	}
 
 	for _, e := range entries {
-		if !m.showHidden && e.Name()[0] == '.' {
+		if !m.showHidden && e.Name[0] == '.' {
 			continue
 		}
-		item := fileItem{name: e.Name(), path: filepath.Join(m.cwd, e.Name()), isDir: e.IsDir()}
-		if e.IsDir() {
+		item := fileItem{name: e.Name, path: m.root.Join(m.cwd, e.Name), backend: m.root, isDir: e.IsDir}
+		if e.IsDir {
 			dirs = append(dirs, item)
 		} else {
 			files = append(files, item)
@@ -168,7 +240,7 @@ func (m *model) refreshBrowse() {
 	}
 
 	items := append(dirs, files...) // Directories first
-	title := fmt.Sprintf("bobafm — %s", m.cwd)
+	title := fmt.Sprintf("bobafm — %s:%s", m.root.String(), m.cwd)
 	if m.showHidden {
 		title += " (hidden)"
 	}
@@ -177,128 +249,55 @@ func (m *model) refreshBrowse() {
 }
 
 func (m *model) refreshMounts() {
-	out,_ := exec.Command("lsblk","-nrpo","NAME,MOUNTPOINT").Output()
+	out, _ := exec.Command("lsblk", "-nrpo", "NAME,MOUNTPOINT").Output()
 	items := []list.Item{}
-	for _, line := range strings.Split(string(out),"\n") {
+	for _, line := range strings.Split(string(out), "\n") {
 		f := strings.Fields(line)
-		if len(f)==0 { continue }
-		mi := mountItem{dev:f[0]}
-		if len(f)>1 { mi.mount=f[1] }
-		items = append(items,mi)
+		if len(f) == 0 {
+			continue
+		}
+		mi := mountItem{dev: f[0]}
+		if len(f) > 1 {
+			mi.mount = f[1]
+		}
+		items = append(items, mi)
+	}
+	for _, rr := range m.remoteRoots {
+		items = append(items, mountItem{dev: rr.spec, remote: true, root: rr})
 	}
 	m.mounts.SetItems(items)
 }
 
-func (m *model) handleInput() {
-	input := strings.TrimSpace(m.input.Value())
-	if input=="" { return }
-	switch m.inputTarget {
-	case "create":
-		full := filepath.Join(m.cwd,input)
-		isDir := strings.HasSuffix(input,"/")
-		parent := filepath.Dir(full)
-		os.MkdirAll(parent,0755)
-		if isDir { os.MkdirAll(full,0755) } else { f,_ := os.OpenFile(full,os.O_CREATE|os.O_EXCL,0644); if f!=nil { f.Close() } }
-	case "rename":
-		item := m.browse.SelectedItem().(fileItem)
-		dst := filepath.Join(m.cwd,input)
-		os.Rename(item.path,dst)
+// parseMountSpec splits "user@host:/path" into its three parts.
+func parseMountSpec(spec string) (user, host, path string, ok bool) {
+	at := strings.IndexByte(spec, '@')
+	colon := strings.IndexByte(spec, ':')
+	if at < 0 || colon < at {
+		return "", "", "", false
 	}
-	m.mode = ModeBrowse
-	m.refreshBrowse()
-}
-
-func keys(m map[string]bool) []string {
-	out := []string{}
-	for k:=range m { out=append(out,k) }
-	return out
-}
-
-func copyFile(src,dst string) {
-	in,_ := os.Open(src); defer in.Close()
-	out,_ := os.Create(dst); defer out.Close()
-	io.Copy(out,in)
+	return spec[:at], spec[at+1 : colon], spec[colon+1:], true
 }
 
 func (m model) Init() tea.Cmd { return nil }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var cmd tea.Cmd
-	switch msg := msg.(type) {
-	case tea.WindowSizeMsg:
-		m.browse.SetSize(msg.Width,msg.Height-3)
-		m.mounts.SetSize(msg.Width,msg.Height-3)
-		m.view.Width = msg.Width
-		m.view.Height = msg.Height-1
-	case tea.KeyMsg:
-		switch m.mode {
-		case ModeBrowse:
-			switch {
-			case key.Matches(msg,m.keys.Quit): return m, tea.Quit
-			case key.Matches(msg,m.keys.ToggleHidden): m.showHidden=!m.showHidden; m.refreshBrowse()
-			case key.Matches(msg,m.keys.Enter):
-				item := m.browse.SelectedItem().(fileItem)
-				if item.isDir { m.cwd=item.path; m.refreshBrowse() } else { return m, tea.ExecProcess(exec.Command(m.editor,item.path),nil) }
-			case key.Matches(msg,m.keys.Mark):
-				item := m.browse.SelectedItem().(fileItem)
-				if !item.isParent { m.marked[item.path]=!m.marked[item.path]; m.refreshBrowse() }
-			case key.Matches(msg,m.keys.Yank): m.clipboard=keys(m.marked); m.clipMode=ClipCopy; m.marked=map[string]bool{}; m.refreshBrowse()
-			case key.Matches(msg,m.keys.Cut): m.clipboard=keys(m.marked); m.clipMode=ClipCut; m.marked=map[string]bool{}; m.refreshBrowse()
-			case key.Matches(msg,m.keys.Paste):
-				for _,src:=range m.clipboard { dst:=filepath.Join(m.cwd,filepath.Base(src)); if m.clipMode==ClipCopy { copyFile(src,dst) } else { os.Rename(src,dst) } }
-				m.clipboard=nil; m.clipMode=ClipNone; m.refreshBrowse()
-			case key.Matches(msg,m.keys.New): m.mode=ModeInput; m.input.SetValue(""); m.inputTarget="create"
-			case key.Matches(msg,m.keys.Rename):
-				item := m.browse.SelectedItem().(fileItem)
-				if !item.isParent { m.mode=ModeInput; m.input.SetValue(item.name); m.inputTarget="rename" }
-			case key.Matches(msg,m.keys.Delete):
-				item := m.browse.SelectedItem().(fileItem)
-				if !item.isParent { os.RemoveAll(item.path); delete(m.marked,item.path); m.refreshBrowse() }
-			case key.Matches(msg,m.keys.View):
-				item := m.browse.SelectedItem().(fileItem)
-				if !item.isParent && !item.isDir { data,_:=os.ReadFile(item.path); m.view.SetContent(string(data)); m.mode=ModeView }
-			case key.Matches(msg,m.keys.Mount): m.refreshMounts(); m.mode=ModeMounts
-			}
-		case ModeMounts:
-			switch {
-			case key.Matches(msg,m.keys.Back): m.mode=ModeBrowse
-			case key.Matches(msg,m.keys.Enter):
-				item := m.mounts.SelectedItem().(mountItem)
-				if item.mount=="" { exec.Command("udisksctl","mount","-b",item.dev).Run(); m.refreshMounts() } else { m.cwd=item.mount; m.refreshBrowse(); m.mode=ModeBrowse }
-			case key.Matches(msg,m.keys.Back):
-				item := m.mounts.SelectedItem().(mountItem)
-				if item.mount!="" { exec.Command("udisksctl","unmount","-b",item.dev).Run(); m.refreshMounts() }
-			}
-		case ModeInput:
-			switch {
-			case key.Matches(msg,m.keys.Back): m.mode=ModeBrowse
-			case key.Matches(msg,key.NewBinding(key.WithKeys("enter"))): m.handleInput()
-			}
-			m.input,cmd = m.input.Update(msg)
-			return m, cmd
-		case ModeView:
-			if key.Matches(msg,m.keys.Back)||key.Matches(msg,m.keys.Quit) { m.mode=ModeBrowse }
-			m.view,cmd = m.view.Update(msg)
-			return m, cmd
-		}
+	if wsz, ok := msg.(tea.WindowSizeMsg); ok {
+		m.browse.SetSize(wsz.Width, wsz.Height-3)
+		m.mounts.SetSize(wsz.Width, wsz.Height-3)
+		m.textarea.SetWidth(wsz.Width)
+		m.textarea.SetHeight(wsz.Height - 3)
+		m.viewport.Width = wsz.Width
+		m.viewport.Height = wsz.Height - 1
 	}
-	if m.mode==ModeBrowse { m.browse,cmd = m.browse.Update(msg) }
-	if m.mode==ModeMounts { m.mounts,cmd = m.mounts.Update(msg) }
-	return m,cmd
+	next, cmd := m.state.handle(&m, msg)
+	m.state = next
+	return m, cmd
 }
 
-func (m model) View() string {
-	switch m.mode {
-	case ModeBrowse: return m.browse.View()
-	case ModeMounts: return m.mounts.View()
-	case ModeInput: return "Input:\n\n"+m.input.View()
-	case ModeView: return m.view.View()
-	}
-	return ""
-}
+func (m model) View() string { return m.state.view(&m) }
 
 func main() {
-	if err := tea.NewProgram(initialModel(), tea.WithAltScreen()).Start(); err!=nil {
+	if err := tea.NewProgram(initialModel(), tea.WithAltScreen()).Start(); err != nil {
 		fmt.Println("Error running bobafm:", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/StikyPiston/bobafm/backend"
+)
+
+// overlay is an in-memory, editable copy of a file, following the LSP
+// notion of an overlay: edits happen against text and are only written
+// back to the backend on an explicit save. hash/diskHash let dirty()
+// answer "has this buffer diverged from disk" without keeping a second
+// copy of the on-disk bytes around.
+type overlay struct {
+	path     string
+	be       backend.Backend
+	text     []byte
+	hash     string
+	diskHash string
+	saved    bool
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// loadOverlay reads path fresh from its backend into a new overlay.
+func loadOverlay(be backend.Backend, path string) (*overlay, error) {
+	r, err := be.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data := readAll(r)
+	h := hashBytes(data)
+	return &overlay{path: path, be: be, text: data, hash: h, diskHash: h, saved: true}, nil
+}
+
+func (o *overlay) dirty() bool { return o.hash != o.diskHash }
+
+// setText records an edit from the textarea buffer.
+func (o *overlay) setText(text string) {
+	o.text = []byte(text)
+	o.hash = hashBytes(o.text)
+	o.saved = !o.dirty()
+}
+
+// save writes the overlay's text back to its backend and marks it
+// clean.
+func (o *overlay) save() error {
+	w, err := o.be.Create(o.path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if _, err := w.Write(o.text); err != nil {
+		return err
+	}
+	o.diskHash = o.hash
+	o.saved = true
+	return nil
+}
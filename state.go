@@ -0,0 +1,442 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/StikyPiston/bobafm/backend"
+	"github.com/StikyPiston/bobafm/fileop"
+)
+
+// state is one node of bobafm's UI state machine, replacing the old
+// flat Mode enum plus stringly-typed inputTarget. Each state owns its
+// own key bindings and prompt, and hands back the state to transition
+// to next — usually itself, after forwarding msg to whatever bubble it
+// owns.
+type state interface {
+	handle(m *model, msg tea.Msg) (state, tea.Cmd)
+	view(m *model) string
+}
+
+// idleState is plain browsing: the default state, and the one every
+// other state returns to when its flow completes or is cancelled.
+type idleState struct{}
+
+func (idleState) view(m *model) string { return m.browse.View() }
+
+func (s idleState) handle(m *model, msg tea.Msg) (state, tea.Cmd) {
+	if km, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(km, m.keys.Quit):
+			return s, tea.Quit
+		case key.Matches(km, m.keys.ToggleHidden):
+			m.showHidden = !m.showHidden
+			m.refreshBrowse()
+			return s, nil
+		case key.Matches(km, m.keys.Enter):
+			item := m.browse.SelectedItem().(fileItem)
+			if item.isDir {
+				m.cwd = item.path
+				m.refreshBrowse()
+				return s, nil
+			}
+			if _, ok := m.root.(backend.Local); ok {
+				return s, tea.ExecProcess(exec.Command(m.editor, item.path), nil)
+			}
+			return s, nil
+		case key.Matches(km, m.keys.Mark):
+			item := m.browse.SelectedItem().(fileItem)
+			if !item.isParent {
+				key := locKey(item.backend, item.path)
+				if _, ok := m.marked[key]; ok {
+					delete(m.marked, key)
+				} else {
+					m.marked[key] = item.loc()
+				}
+				m.refreshBrowse()
+			}
+			return s, nil
+		case key.Matches(km, m.keys.Yank):
+			m.clipboard = markedLocations(m.marked)
+			m.clipMode = ClipCopy
+			m.marked = map[string]fileop.Location{}
+			m.refreshBrowse()
+			return s, nil
+		case key.Matches(km, m.keys.Cut):
+			m.clipboard = markedLocations(m.marked)
+			m.clipMode = ClipCut
+			m.marked = map[string]fileop.Location{}
+			m.refreshBrowse()
+			return s, nil
+		case key.Matches(km, m.keys.Paste):
+			if len(m.clipboard) == 0 {
+				return s, nil
+			}
+			return newConfirmState(m, planPaste(m)), nil
+		case key.Matches(km, m.keys.NewFile):
+			return newCreateState(m, false), nil
+		case key.Matches(km, m.keys.NewDir):
+			return newCreateState(m, true), nil
+		case key.Matches(km, m.keys.Rename):
+			item := m.browse.SelectedItem().(fileItem)
+			if !item.isParent {
+				return newRenameState(m, item), nil
+			}
+			return s, nil
+		case key.Matches(km, m.keys.Move):
+			item := m.browse.SelectedItem().(fileItem)
+			if !item.isParent {
+				return newMoveState(m, item), nil
+			}
+			return s, nil
+		case key.Matches(km, m.keys.Delete):
+			if len(m.marked) > 0 {
+				return newConfirmState(m, planDeletes(markedLocations(m.marked))), nil
+			}
+			item := m.browse.SelectedItem().(fileItem)
+			if !item.isParent {
+				return newDeleteConfirmState(item), nil
+			}
+			return s, nil
+		case key.Matches(km, m.keys.View):
+			item := m.browse.SelectedItem().(fileItem)
+			if !item.isParent && !item.isDir {
+				if vs, ok := newViewState(m, item); ok {
+					return vs, nil
+				}
+			}
+			return s, nil
+		case key.Matches(km, m.keys.Mount):
+			m.refreshMounts()
+			return mountState{}, nil
+		case key.Matches(km, m.keys.Scan):
+			item := m.browse.SelectedItem().(fileItem)
+			if item.isDir && !item.isParent {
+				if _, err := cachedScanDir(m.dirStats, item.backend, item.path); err == nil {
+					m.refreshBrowse()
+				}
+			}
+			return s, nil
+		case key.Matches(km, m.keys.Verify):
+			item := m.browse.SelectedItem().(fileItem)
+			if item.isDir && !item.isParent {
+				key := locKey(item.backend, item.path)
+				if cached, ok := m.dirStats[key]; ok {
+					if fresh, err := scanDir(item.backend, item.path); err == nil {
+						cached.changed = fresh.hash != cached.hash
+						m.refreshBrowse()
+					}
+				}
+			}
+			return s, nil
+		case key.Matches(km, m.keys.Undo):
+			m.journal.undoStep()
+			m.refreshBrowse()
+			return s, nil
+		case key.Matches(km, m.keys.Redo):
+			m.journal.redoStep()
+			m.refreshBrowse()
+			return s, nil
+		case key.Matches(km, m.keys.EmptyTrash):
+			fileop.EmptyTrash(m.root, m.cwd)
+			m.refreshBrowse()
+			return s, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.browse, cmd = m.browse.Update(msg)
+	return s, cmd
+}
+
+// createState handles both CreateFileState and CreateDirectoryState:
+// the two only differ in which fileop action Enter runs, so they share
+// an implementation parameterised by dir.
+type createState struct{ dir bool }
+
+func newCreateState(m *model, dir bool) state {
+	m.input.SetValue("")
+	m.input.Focus()
+	return createState{dir: dir}
+}
+
+func (s createState) view(m *model) string {
+	prompt := "New file name:"
+	if s.dir {
+		prompt = "New directory name:"
+	}
+	return prompt + "\n\n" + m.input.View()
+}
+
+func (s createState) handle(m *model, msg tea.Msg) (state, tea.Cmd) {
+	if km, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(km, m.keys.Back):
+			return idleState{}, nil
+		case km.Type == tea.KeyEnter:
+			if input := strings.TrimSpace(m.input.Value()); input != "" {
+				full := m.root.Join(m.cwd, input)
+				parent := m.root.Dir(full)
+				actions := []fileop.Action{fileop.Mkdir(fileop.Location{Backend: m.root, Path: parent})}
+				if s.dir {
+					actions = append(actions, fileop.Mkdir(fileop.Location{Backend: m.root, Path: full}))
+				} else {
+					actions = append(actions, fileop.Mkfile(fileop.Location{Backend: m.root, Path: full}, nil))
+				}
+				if reverses, err := fileop.Run(actions); err == nil {
+					m.journal.push(reverses)
+				}
+				m.refreshBrowse()
+			}
+			return idleState{}, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return s, cmd
+}
+
+// renameState prompts for a new name for item, within its current
+// directory.
+type renameState struct{ item fileItem }
+
+func newRenameState(m *model, item fileItem) state {
+	m.input.SetValue(item.name)
+	m.input.Focus()
+	return renameState{item: item}
+}
+
+func (s renameState) view(m *model) string {
+	return "Rename " + s.item.name + " to:\n\n" + m.input.View()
+}
+
+func (s renameState) handle(m *model, msg tea.Msg) (state, tea.Cmd) {
+	if km, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(km, m.keys.Back):
+			return idleState{}, nil
+		case km.Type == tea.KeyEnter:
+			if input := strings.TrimSpace(m.input.Value()); input != "" {
+				dst := m.root.Join(m.cwd, input)
+				if reverses, err := fileop.Run([]fileop.Action{fileop.Move(s.item.loc(), fileop.Location{Backend: m.root, Path: dst})}); err == nil {
+					m.journal.push(reverses)
+				}
+				m.refreshBrowse()
+			}
+			return idleState{}, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return s, cmd
+}
+
+// moveState prompts for a destination path for item, letting it move
+// to a different directory entirely rather than just renaming in
+// place.
+type moveState struct{ item fileItem }
+
+func newMoveState(m *model, item fileItem) state {
+	m.input.SetValue(item.path)
+	m.input.Focus()
+	return moveState{item: item}
+}
+
+func (s moveState) view(m *model) string {
+	return "Move " + s.item.name + " to:\n\n" + m.input.View()
+}
+
+func (s moveState) handle(m *model, msg tea.Msg) (state, tea.Cmd) {
+	if km, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(km, m.keys.Back):
+			return idleState{}, nil
+		case km.Type == tea.KeyEnter:
+			if dst := strings.TrimSpace(m.input.Value()); dst != "" {
+				if reverses, err := fileop.Run([]fileop.Action{fileop.Move(s.item.loc(), fileop.Location{Backend: s.item.backend, Path: dst})}); err == nil {
+					m.journal.push(reverses)
+				}
+				m.refreshBrowse()
+			}
+			return idleState{}, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return s, cmd
+}
+
+// deleteConfirmState requires an explicit y/n before a destructive
+// delete runs.
+type deleteConfirmState struct{ item fileItem }
+
+func newDeleteConfirmState(item fileItem) state { return deleteConfirmState{item: item} }
+
+func (s deleteConfirmState) view(m *model) string {
+	return "Delete " + s.item.name + "? (y/n)"
+}
+
+func (s deleteConfirmState) handle(m *model, msg tea.Msg) (state, tea.Cmd) {
+	if km, ok := msg.(tea.KeyMsg); ok {
+		switch km.String() {
+		case "y":
+			if reverses, err := fileop.Run([]fileop.Action{fileop.Remove(s.item.loc())}); err == nil {
+				m.journal.push(reverses)
+			}
+			delete(m.marked, locKey(s.item.backend, s.item.path))
+			m.refreshBrowse()
+			return idleState{}, nil
+		case "n", "esc":
+			return idleState{}, nil
+		}
+	}
+	return s, nil
+}
+
+// mountState lists local block devices and connected remote roots.
+type mountState struct{}
+
+func (mountState) view(m *model) string { return m.mounts.View() }
+
+func (s mountState) handle(m *model, msg tea.Msg) (state, tea.Cmd) {
+	if km, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(km, m.keys.Back):
+			return idleState{}, nil
+		case key.Matches(km, m.keys.AddMount):
+			return newAddMountState(m), nil
+		case key.Matches(km, m.keys.Enter):
+			item := m.mounts.SelectedItem().(mountItem)
+			switch {
+			case item.remote:
+				m.root = item.root.be
+				m.cwd = item.root.path
+				m.refreshBrowse()
+				return idleState{}, nil
+			case item.mount == "":
+				exec.Command("udisksctl", "mount", "-b", item.dev).Run()
+				m.refreshMounts()
+				return s, nil
+			default:
+				m.root = backend.Local{}
+				m.cwd = item.mount
+				m.refreshBrowse()
+				return idleState{}, nil
+			}
+		case key.Matches(km, m.keys.Delete):
+			item := m.mounts.SelectedItem().(mountItem)
+			if !item.remote && item.mount != "" {
+				exec.Command("udisksctl", "unmount", "-b", item.dev).Run()
+				m.refreshMounts()
+			}
+			return s, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.mounts, cmd = m.mounts.Update(msg)
+	return s, cmd
+}
+
+// addMountState prompts for a "user@host:/path" spec and dials it as a
+// new remote root.
+type addMountState struct{}
+
+func newAddMountState(m *model) state {
+	m.input.SetValue("")
+	m.input.Focus()
+	return addMountState{}
+}
+
+func (addMountState) view(m *model) string {
+	return "Add remote mount (user@host:/path):\n\n" + m.input.View()
+}
+
+func (s addMountState) handle(m *model, msg tea.Msg) (state, tea.Cmd) {
+	if km, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(km, m.keys.Back):
+			return mountState{}, nil
+		case km.Type == tea.KeyEnter:
+			if spec := strings.TrimSpace(m.input.Value()); spec != "" {
+				if user, host, path, ok := parseMountSpec(spec); ok {
+					if be, err := backend.DialSFTP(user, host); err == nil {
+						m.remoteRoots = append(m.remoteRoots, &remoteRoot{spec: spec, be: be, path: path})
+					}
+				}
+			}
+			m.refreshMounts()
+			return mountState{}, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return s, cmd
+}
+
+// viewState is the overlay-backed file editor. key identifies the
+// overlay being edited; warn is set once an attempt to leave with
+// unsaved changes has already been made, so a second press is needed to
+// actually discard the view.
+type viewState struct {
+	key  string
+	warn bool
+}
+
+func newViewState(m *model, item fileItem) (state, bool) {
+	key := locKey(item.backend, item.path)
+	ov, ok := m.overlays[key]
+	if !ok {
+		loaded, err := loadOverlay(item.backend, item.path)
+		if err != nil {
+			return nil, false
+		}
+		ov = loaded
+		m.overlays[key] = ov
+	}
+	m.textarea.SetValue(string(ov.text))
+	m.textarea.Focus()
+	return viewState{key: key}, true
+}
+
+func (s viewState) view(m *model) string {
+	header := "Editing " + s.key
+	if ov, ok := m.overlays[s.key]; ok && ov.dirty() {
+		header += " [Modified]"
+	}
+	if s.warn {
+		header += " — unsaved changes, press esc again to discard the view (ctrl+s to save)"
+	}
+	return header + "\n\n" + m.textarea.View()
+}
+
+func (s viewState) handle(m *model, msg tea.Msg) (state, tea.Cmd) {
+	ov := m.overlays[s.key]
+	if km, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(km, m.keys.Save):
+			if ov != nil {
+				ov.setText(m.textarea.Value())
+				if ov.dirty() {
+					if err := ov.save(); err == nil {
+						m.refreshBrowse()
+					}
+				}
+			}
+			return viewState{key: s.key}, nil
+		case key.Matches(km, m.keys.Back):
+			if ov != nil {
+				ov.setText(m.textarea.Value())
+			}
+			if ov != nil && ov.dirty() && !s.warn {
+				return viewState{key: s.key, warn: true}, nil
+			}
+			m.refreshBrowse()
+			return idleState{}, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.textarea, cmd = m.textarea.Update(msg)
+	return viewState{key: s.key}, cmd
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io"
+
+	"github.com/StikyPiston/bobafm/fileop"
+)
+
+func markedLocations(marked map[string]fileop.Location) []fileop.Location {
+	out := make([]fileop.Location, 0, len(marked))
+	for _, loc := range marked {
+		out = append(out, loc)
+	}
+	return out
+}
+
+// baseName splits off the final path element. It doesn't use
+// path/filepath since a Location's separator depends on which backend
+// it belongs to (SFTP paths are always "/"-separated, even from a
+// Windows client).
+func baseName(path string) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '/' || path[i] == '\\' {
+			return path[i+1:]
+		}
+	}
+	return path
+}
+
+func readAll(r io.Reader) []byte {
+	data, _ := io.ReadAll(r)
+	return data
+}